@@ -2,55 +2,114 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-// broadcastMessage defines the message payload.
+// defaultBroadcastCount is how many messages broadcastHandler sends when the
+// request omits the count query parameter.
+const defaultBroadcastCount = 1
+
+// broadcastMessage defines the message payload. SentNs is the publisher's
+// send timestamp, used by receivers to compute end-to-end propagation
+// latency. Seq and Payload let experiments generate batches of padded
+// messages of a configurable size to exercise mesh behavior at scale.
+// RunID is populated when a message was published as part of a structured
+// experiment (see experiment.go), so receivers can attribute it to that run.
 type broadcastMessage struct {
-	MsgID string `json:"msg_id"`
+	MsgID   string `json:"msg_id"`
+	RunID   string `json:"run_id,omitempty"`
+	Seq     int    `json:"seq"`
+	SentNs  int64  `json:"sent_ns"`
+	Payload []byte `json:"payload,omitempty"`
 }
 
-// broadcastHandler creates an http.HandlerFunc that publishes a message
-// to a gossipsub topic when invoked.
+// broadcastHandler creates an http.HandlerFunc that publishes one or more
+// messages to a gossipsub topic when invoked. The optional "size" query
+// parameter pads each message with that many random bytes, and the optional
+// "count" query parameter sends that many messages, each tagged with its
+// own msg_id and sequence number.
 func broadcastHandler(topic *pubsub.Topic) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		msgID := uuid.New().String()
-		bMsg := broadcastMessage{MsgID: msgID}
-		msgBytes, err := json.Marshal(bMsg)
+		size, err := parseNonNegativeIntParam(r, "size", 0)
 		if err != nil {
-			http.Error(
-				w,
-				"Failed to marshal message",
-				http.StatusInternalServerError,
-			)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		count, err := parseNonNegativeIntParam(r, "count", defaultBroadcastCount)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		logJSON(logMessage{
-			Event:       "message_broadcast",
-			MsgID:       msgID,
-			TimestampNs: time.Now().UnixNano(),
-		})
+		batchID := uuid.New().String()
+		for seq := 0; seq < count; seq++ {
+			payload := make([]byte, size)
+			if size > 0 {
+				if _, err := rand.Read(payload); err != nil {
+					http.Error(w, "Failed to generate payload", http.StatusInternalServerError)
+					return
+				}
+			}
+
+			msgID := fmt.Sprintf("%s-%d", batchID, seq)
+			sentNs := time.Now().UnixNano()
+			bMsg := broadcastMessage{MsgID: msgID, Seq: seq, SentNs: sentNs, Payload: payload}
+			msgBytes, err := json.Marshal(bMsg)
+			if err != nil {
+				http.Error(w, "Failed to marshal message", http.StatusInternalServerError)
+				return
+			}
 
-		if err := topic.Publish(context.Background(), msgBytes); err != nil {
-			log.Printf("Failed to publish message: %v", err)
+			logJSON(logMessage{
+				Event:       "message_broadcast",
+				MsgID:       msgID,
+				Seq:         seq,
+				TimestampNs: sentNs,
+			})
+
+			if err := topic.Publish(context.Background(), msgBytes); err != nil {
+				log.Printf("Failed to publish message: %v", err)
+				continue
+			}
+			messagesPublishedTotal.Inc()
 		}
 
-		fmt.Fprintf(w, "Broadcast message with ID: %s\n", msgID)
+		fmt.Fprintf(w, "Broadcast %d message(s) with batch ID: %s\n", count, batchID)
+	}
+}
+
+// parseNonNegativeIntParam reads a non-negative integer query parameter,
+// returning def if the parameter is absent.
+func parseNonNegativeIntParam(r *http.Request, name string, def int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid %s parameter: %q", name, raw)
 	}
+	return v, nil
 }
 
 // startAPIServer initializes and runs the HTTP server in a goroutine.
-func startAPIServer(listenAddr string, topic *pubsub.Topic) {
+func startAPIServer(ctx context.Context, listenAddr string, topic *pubsub.Topic, liveness *livenessTracker, experiments *experimentManager) {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/broadcast", broadcastHandler(topic))
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/peers", peersHandler(liveness))
+	mux.HandleFunc("/experiment", experimentHandler(ctx, topic, experiments))
+	mux.HandleFunc("/experiment/", experimentStatusHandler(experiments))
 
 	go func() {
 		if err := http.ListenAndServe(listenAddr, mux); err != nil {
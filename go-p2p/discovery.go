@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	discovery "github.com/libp2p/go-libp2p-discovery"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+)
+
+// discoveryAdvertiseInterval and discoveryFindPeersInterval control how
+// often this node re-advertises itself and searches for new peers under the
+// rendezvous string.
+const (
+	discoveryAdvertiseInterval = 1 * time.Minute
+	discoveryFindPeersInterval = 30 * time.Second
+)
+
+// runDiscovery advertises this node under rendezvous and periodically looks
+// up other peers advertising the same rendezvous, connecting to any that
+// aren't already connected. It blocks until ctx is cancelled, so it should be
+// run in its own goroutine.
+func runDiscovery(ctx context.Context, idht *dht.IpfsDHT, h host.Host, rendezvous string) {
+	routingDiscovery := discovery.NewRoutingDiscovery(idht)
+	discovery.Advertise(ctx, routingDiscovery, rendezvous, discovery.TTL(discoveryAdvertiseInterval))
+
+	ticker := time.NewTicker(discoveryFindPeersInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			peerCh, err := routingDiscovery.FindPeers(ctx, rendezvous)
+			if err != nil {
+				log.Printf("Peer discovery failed: %v", err)
+				continue
+			}
+			for p := range peerCh {
+				if p.ID == h.ID() || len(p.Addrs) == 0 {
+					continue
+				}
+				if h.Network().Connectedness(p.ID) == network.Connected {
+					continue
+				}
+				if err := h.Connect(ctx, p); err != nil {
+					log.Printf("Failed to connect to discovered peer %s: %v", p.ID, err)
+				}
+			}
+		}
+	}
+}
+
+// connectionLogger is a network.Notifiee that emits peer_connected and
+// peer_disconnected JSON events so the orchestrator can build the actual
+// mesh graph over time instead of assuming static topology.
+type connectionLogger struct {
+	network.NoopNotifiee
+}
+
+func newConnectionLogger() *connectionLogger {
+	return &connectionLogger{}
+}
+
+func (c *connectionLogger) Connected(_ network.Network, conn network.Conn) {
+	logJSON(logMessage{
+		Event:       "peer_connected",
+		Sender:      conn.RemotePeer().String(),
+		TimestampNs: time.Now().UnixNano(),
+	})
+}
+
+func (c *connectionLogger) Disconnected(_ network.Network, conn network.Conn) {
+	logJSON(logMessage{
+		Event:       "peer_disconnected",
+		Sender:      conn.RemotePeer().String(),
+		TimestampNs: time.Now().UnixNano(),
+	})
+}
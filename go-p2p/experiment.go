@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+// experimentSpec is the body of a POST /experiment request. It describes a
+// controlled publish schedule the orchestrator can drive deterministically
+// across dozens of peers and compare runs against.
+type experimentSpec struct {
+	RunID       string  `json:"run_id"`
+	RateHz      float64 `json:"rate_hz"`
+	DurationS   float64 `json:"duration_s"`
+	PayloadSize int     `json:"payload_size"`
+	WarmupS     float64 `json:"warmup_s"`
+}
+
+// experimentEvent is one row of a run's per-run JSONL/CSV log.
+type experimentEvent struct {
+	Event       string `json:"event"`
+	RunID       string `json:"run_id"`
+	MsgID       string `json:"msg_id"`
+	Seq         int    `json:"seq"`
+	Peer        string `json:"peer,omitempty"`
+	TimestampNs int64  `json:"timestamp_ns"`
+}
+
+var experimentEventCSVHeader = []string{"event", "run_id", "msg_id", "seq", "peer", "timestamp_ns"}
+
+func (e experimentEvent) csvRow() []string {
+	return []string{
+		e.Event,
+		e.RunID,
+		e.MsgID,
+		strconv.Itoa(e.Seq),
+		e.Peer,
+		strconv.FormatInt(e.TimestampNs, 10),
+	}
+}
+
+// experimentStatus is the aggregated-stats response for
+// GET /experiment/{run_id}/status.
+type experimentStatus struct {
+	RunID          string  `json:"run_id"`
+	State          string  `json:"state"` // "running" or "complete"
+	Delivered      int     `json:"delivered"`
+	Duplicates     int     `json:"duplicates"`
+	P50LatencyMs   float64 `json:"p50_latency_ms"`
+	P95LatencyMs   float64 `json:"p95_latency_ms"`
+	P99LatencyMs   float64 `json:"p99_latency_ms"`
+	FirstReceiveNs int64   `json:"first_receive_ns,omitempty"`
+	LastReceiveNs  int64   `json:"last_receive_ns,omitempty"`
+}
+
+// experimentRun tracks the live state of one in-flight or completed
+// experiment.
+type experimentRun struct {
+	spec      experimentSpec
+	startedNs int64
+
+	mu             sync.Mutex
+	seenMsgIDs     map[string]struct{}
+	latenciesMs    []float64
+	delivered      int
+	duplicates     int
+	firstReceiveNs int64
+	lastReceiveNs  int64
+
+	jsonl *os.File
+	csv   *csv.Writer
+}
+
+func (run *experimentRun) logEvent(ev experimentEvent) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+
+	if b, err := json.Marshal(ev); err == nil {
+		run.jsonl.Write(append(b, '\n'))
+	}
+	run.csv.Write(ev.csvRow())
+	run.csv.Flush()
+}
+
+func (run *experimentRun) recordReceive(msgID, peerID string, seq int, sentNs, receivedNs int64) {
+	run.mu.Lock()
+	if _, seen := run.seenMsgIDs[msgID]; seen {
+		run.duplicates++
+		run.mu.Unlock()
+		return
+	}
+	run.seenMsgIDs[msgID] = struct{}{}
+	run.delivered++
+	run.latenciesMs = append(run.latenciesMs, float64(receivedNs-sentNs)/float64(time.Millisecond))
+	if run.firstReceiveNs == 0 || receivedNs < run.firstReceiveNs {
+		run.firstReceiveNs = receivedNs
+	}
+	if receivedNs > run.lastReceiveNs {
+		run.lastReceiveNs = receivedNs
+	}
+	run.mu.Unlock()
+
+	run.logEvent(experimentEvent{
+		Event: "message_received", RunID: run.spec.RunID, MsgID: msgID,
+		Seq: seq, Peer: peerID, TimestampNs: receivedNs,
+	})
+}
+
+// status computes the run's aggregated stats. A run is "complete" once
+// quiescenceWindow has elapsed since the last receipt. A node that never
+// receives anything for this run (e.g. a listener-only node with no
+// matching traffic) is instead marked complete once the publish schedule
+// would have finished plus quiescenceWindow.
+func (run *experimentRun) status(quiescenceWindow time.Duration) experimentStatus {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+
+	sorted := append([]float64(nil), run.latenciesMs...)
+	sort.Float64s(sorted)
+
+	state := "running"
+	if run.lastReceiveNs != 0 {
+		if time.Since(time.Unix(0, run.lastReceiveNs)) > quiescenceWindow {
+			state = "complete"
+		}
+	} else {
+		scheduleDuration := time.Duration((run.spec.WarmupS + run.spec.DurationS) * float64(time.Second))
+		if time.Since(time.Unix(0, run.startedNs)) > scheduleDuration+quiescenceWindow {
+			state = "complete"
+		}
+	}
+
+	return experimentStatus{
+		RunID:          run.spec.RunID,
+		State:          state,
+		Delivered:      run.delivered,
+		Duplicates:     run.duplicates,
+		P50LatencyMs:   percentile(sorted, 0.50),
+		P95LatencyMs:   percentile(sorted, 0.95),
+		P99LatencyMs:   percentile(sorted, 0.99),
+		FirstReceiveNs: run.firstReceiveNs,
+		LastReceiveNs:  run.lastReceiveNs,
+	}
+}
+
+// percentile returns the p-th percentile (0..1) of a pre-sorted slice.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(p * float64(len(sorted)-1))
+	return sorted[idx]
+}
+
+// experimentManager owns every experiment run on this node, keyed by run ID.
+type experimentManager struct {
+	dataDir          string
+	quiescenceWindow time.Duration
+
+	mu   sync.Mutex
+	runs map[string]*experimentRun
+}
+
+func newExperimentManager(dataDir string, quiescenceWindow time.Duration) *experimentManager {
+	return &experimentManager{
+		dataDir:          dataDir,
+		quiescenceWindow: quiescenceWindow,
+		runs:             make(map[string]*experimentRun),
+	}
+}
+
+func (m *experimentManager) start(spec experimentSpec) (*experimentRun, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.runs[spec.RunID]; exists {
+		return nil, fmt.Errorf("run %q already exists", spec.RunID)
+	}
+
+	if err := os.MkdirAll(m.dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data dir: %w", err)
+	}
+	jsonl, err := os.Create(filepath.Join(m.dataDir, spec.RunID+".jsonl"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create jsonl file: %w", err)
+	}
+	csvFile, err := os.Create(filepath.Join(m.dataDir, spec.RunID+".csv"))
+	if err != nil {
+		jsonl.Close()
+		return nil, fmt.Errorf("failed to create csv file: %w", err)
+	}
+	csvWriter := csv.NewWriter(csvFile)
+	csvWriter.Write(experimentEventCSVHeader)
+	csvWriter.Flush()
+
+	run := &experimentRun{
+		spec:       spec,
+		startedNs:  time.Now().UnixNano(),
+		seenMsgIDs: make(map[string]struct{}),
+		jsonl:      jsonl,
+		csv:        csvWriter,
+	}
+	m.runs[spec.RunID] = run
+	return run, nil
+}
+
+func (m *experimentManager) get(runID string) (*experimentRun, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	run, ok := m.runs[runID]
+	return run, ok
+}
+
+func (m *experimentManager) recordReceive(runID, msgID, peerID string, seq int, sentNs, receivedNs int64) {
+	run, ok := m.get(runID)
+	if !ok {
+		return
+	}
+	run.recordReceive(msgID, peerID, seq, sentNs, receivedNs)
+}
+
+// runSchedule publishes the configured rate/duration/payload-size schedule
+// for a run, after waiting out its warmup period. A spec with rate_hz == 0
+// starts a receive-only run on this node (e.g. a passive listener in a
+// multi-node experiment) and never publishes. It blocks until the schedule
+// finishes, so it should be run in its own goroutine.
+func runSchedule(ctx context.Context, topic *pubsub.Topic, run *experimentRun) {
+	spec := run.spec
+
+	if spec.WarmupS > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(spec.WarmupS * float64(time.Second))):
+		}
+	}
+
+	if spec.RateHz == 0 {
+		return
+	}
+	interval := time.Duration(float64(time.Second) / spec.RateHz)
+	deadline := time.Now().Add(time.Duration(spec.DurationS * float64(time.Second)))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	seq := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if now.After(deadline) {
+				return
+			}
+			publishExperimentMessage(ctx, topic, run, seq)
+			seq++
+		}
+	}
+}
+
+func publishExperimentMessage(ctx context.Context, topic *pubsub.Topic, run *experimentRun, seq int) {
+	payload := make([]byte, run.spec.PayloadSize)
+	if run.spec.PayloadSize > 0 {
+		if _, err := rand.Read(payload); err != nil {
+			log.Printf("Failed to generate experiment payload: %v", err)
+			return
+		}
+	}
+
+	msgID := fmt.Sprintf("%s-%d", run.spec.RunID, seq)
+	sentNs := time.Now().UnixNano()
+	bMsg := broadcastMessage{MsgID: msgID, RunID: run.spec.RunID, Seq: seq, SentNs: sentNs, Payload: payload}
+	msgBytes, err := json.Marshal(bMsg)
+	if err != nil {
+		log.Printf("Failed to marshal experiment message: %v", err)
+		return
+	}
+
+	run.logEvent(experimentEvent{
+		Event: "message_broadcast", RunID: run.spec.RunID, MsgID: msgID,
+		Seq: seq, TimestampNs: sentNs,
+	})
+
+	if err := topic.Publish(ctx, msgBytes); err != nil {
+		log.Printf("Failed to publish experiment message: %v", err)
+		return
+	}
+	messagesPublishedTotal.Inc()
+}
+
+// validateExperimentSpec rejects specs that would otherwise panic or hang
+// the publish schedule, the same way parseNonNegativeIntParam guards the
+// query-param version of this same feature in broadcastHandler.
+func validateExperimentSpec(spec experimentSpec) error {
+	if spec.RunID == "" {
+		return fmt.Errorf("run_id is required")
+	}
+	if spec.RunID != filepath.Base(spec.RunID) {
+		return fmt.Errorf("run_id must not contain path separators, got %q", spec.RunID)
+	}
+	if spec.PayloadSize < 0 {
+		return fmt.Errorf("payload_size must be >= 0, got %d", spec.PayloadSize)
+	}
+	if spec.RateHz < 0 {
+		return fmt.Errorf("rate_hz must be >= 0 (0 means receive-only), got %v", spec.RateHz)
+	}
+	if spec.RateHz > float64(time.Second) {
+		return fmt.Errorf("rate_hz must be <= %d, got %v", int64(time.Second), spec.RateHz)
+	}
+	if spec.DurationS <= 0 {
+		return fmt.Errorf("duration_s must be > 0, got %v", spec.DurationS)
+	}
+	if spec.WarmupS < 0 {
+		return fmt.Errorf("warmup_s must be >= 0, got %v", spec.WarmupS)
+	}
+	return nil
+}
+
+// experimentHandler creates an http.HandlerFunc for POST /experiment.
+func experimentHandler(ctx context.Context, topic *pubsub.Topic, manager *experimentManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var spec experimentSpec
+		if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid experiment spec: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := validateExperimentSpec(spec); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		run, err := manager.start(spec)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+
+		go runSchedule(ctx, topic, run)
+
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintf(w, "Started experiment %s\n", spec.RunID)
+	}
+}
+
+// experimentStatusHandler creates an http.HandlerFunc for
+// GET /experiment/{run_id}/status.
+func experimentStatusHandler(manager *experimentManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		runID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/experiment/"), "/status")
+		run, ok := manager.get(runID)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(run.status(manager.quiescenceWindow)); err != nil {
+			http.Error(w, "Failed to encode status", http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+// gossipSubConfig collects the GossipSub tuning knobs exposed on the command
+// line so experiments can reproduce FloodSub-style vs mesh-tuned behavior
+// instead of being stuck with library defaults.
+type gossipSubConfig struct {
+	D, Dlo, Dhi  int
+	Heartbeat    time.Duration
+	FloodPublish bool
+	PeerExchange bool
+	SignMessages bool
+}
+
+// gossipSubOptions turns a gossipSubConfig into pubsub.Options, leaving
+// library defaults in place for any zero-valued mesh parameter.
+func gossipSubOptions(cfg gossipSubConfig) []pubsub.Option {
+	params := pubsub.DefaultGossipSubParams()
+	if cfg.D > 0 {
+		params.D = cfg.D
+	}
+	if cfg.Dlo > 0 {
+		params.Dlo = cfg.Dlo
+	}
+	if cfg.Dhi > 0 {
+		params.Dhi = cfg.Dhi
+	}
+	if cfg.Heartbeat > 0 {
+		params.HeartbeatInterval = cfg.Heartbeat
+	}
+
+	opts := []pubsub.Option{
+		pubsub.WithGossipSubParams(params),
+		pubsub.WithFloodPublish(cfg.FloodPublish),
+		pubsub.WithPeerExchange(cfg.PeerExchange),
+	}
+
+	if cfg.SignMessages {
+		opts = append(opts, pubsub.WithMessageSignaturePolicy(pubsub.StrictSign))
+	} else {
+		opts = append(opts, pubsub.WithMessageSignaturePolicy(pubsub.StrictNoSign))
+	}
+
+	return opts
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// heartbeatTopicName is the dedicated gossipsub topic heartbeats are
+// published on, separate from the broadcast topic so liveness traffic never
+// competes with experiment traffic for the same stream.
+const heartbeatTopicName = "/heartbeat/1"
+
+// heartbeatVersion identifies the wire format of the heartbeat payload.
+const heartbeatVersion = "1"
+
+// peerLiveness is what /peers reports about a single peer.
+type peerLiveness struct {
+	LastSeenNs int64 `json:"last_seen_ns"`
+	SkewNs     int64 `json:"skew_ns"`
+}
+
+// livenessTracker records the last heartbeat seen from each peer.
+type livenessTracker struct {
+	mu    sync.Mutex
+	peers map[string]peerLiveness
+}
+
+func newLivenessTracker() *livenessTracker {
+	return &livenessTracker{peers: make(map[string]peerLiveness)}
+}
+
+func (t *livenessTracker) record(peerID string, receivedNs, sentNs int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.peers[peerID] = peerLiveness{
+		LastSeenNs: receivedNs,
+		SkewNs:     receivedNs - sentNs,
+	}
+}
+
+func (t *livenessTracker) snapshot() map[string]peerLiveness {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[string]peerLiveness, len(t.peers))
+	for k, v := range t.peers {
+		out[k] = v
+	}
+	return out
+}
+
+// startHeartbeat publishes a signed heartbeat on topic every interval until
+// ctx is cancelled. It blocks, so it should be run in its own goroutine.
+func startHeartbeat(ctx context.Context, topic *pubsub.Topic, selfID peer.ID, priv crypto.PrivKey, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var seq uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			hb := heartbeat{
+				PeerID:  selfID.String(),
+				Seq:     seq,
+				SentNs:  time.Now().UnixNano(),
+				Version: heartbeatVersion,
+			}
+			seq++
+
+			sig, err := priv.Sign(hb.marshal())
+			if err != nil {
+				log.Printf("Failed to sign heartbeat: %v", err)
+				continue
+			}
+
+			msgBytes := signedHeartbeat{Heartbeat: hb, Signature: sig}.marshal()
+			if err := topic.Publish(ctx, msgBytes); err != nil {
+				log.Printf("Failed to publish heartbeat: %v", err)
+			}
+		}
+	}
+}
+
+// handleHeartbeats verifies and records incoming heartbeats on sub until ctx
+// is cancelled or the subscription errors.
+func handleHeartbeats(ctx context.Context, sub *pubsub.Subscription, selfID peer.ID, tracker *livenessTracker) {
+	for {
+		msg, err := sub.Next(ctx)
+		if err != nil {
+			return // Context cancellation will trigger an error.
+		}
+		if msg.GetFrom() == selfID {
+			continue
+		}
+
+		shb, err := unmarshalSignedHeartbeat(msg.Data)
+		if err != nil {
+			continue // Ignore malformed messages.
+		}
+
+		if !verifyHeartbeat(shb) {
+			log.Printf("Dropping heartbeat with invalid signature from %s", shb.Heartbeat.PeerID)
+			continue
+		}
+
+		receivedNs := time.Now().UnixNano()
+		tracker.record(shb.Heartbeat.PeerID, receivedNs, shb.Heartbeat.SentNs)
+
+		logJSON(logMessage{
+			Event:       "heartbeat_received",
+			Sender:      shb.Heartbeat.PeerID,
+			TimestampNs: receivedNs,
+			DelayNs:     receivedNs - shb.Heartbeat.SentNs,
+		})
+	}
+}
+
+// verifyHeartbeat checks the heartbeat's signature against the public key
+// embedded in the sender's libp2p peer ID.
+func verifyHeartbeat(shb signedHeartbeat) bool {
+	pid, err := peer.Decode(shb.Heartbeat.PeerID)
+	if err != nil {
+		return false
+	}
+	pub, err := pid.ExtractPublicKey()
+	if err != nil {
+		return false
+	}
+
+	ok, err := pub.Verify(shb.Heartbeat.marshal(), shb.Signature)
+	return err == nil && ok
+}
+
+// peersHandler returns an http.HandlerFunc that reports last-seen timestamps
+// and estimated clock skew per peer, derived from received heartbeats.
+func peersHandler(tracker *livenessTracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(tracker.snapshot()); err != nil {
+			http.Error(w, "Failed to encode peer liveness", http.StatusInternalServerError)
+		}
+	}
+}
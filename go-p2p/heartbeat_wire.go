@@ -0,0 +1,154 @@
+package main
+
+// Hand-written wire encoding for heartbeat.proto using protowire directly,
+// rather than protoc/buf-generated code — there's no generator wired into
+// this repo's build. If that changes, regenerate from heartbeat.proto and
+// delete this file instead of editing it.
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// heartbeat is the protobuf payload each node signs and publishes
+// periodically to establish a per-link liveness baseline independent of
+// on-demand /broadcast traffic. Wire format (see heartbeat.proto):
+//
+//	message Heartbeat {
+//	  string peer_id = 1;
+//	  uint64 seq     = 2;
+//	  int64  sent_ns = 3;
+//	  string version = 4;
+//	}
+type heartbeat struct {
+	PeerID  string
+	Seq     uint64
+	SentNs  int64
+	Version string
+}
+
+// marshal encodes the heartbeat using the protobuf wire format.
+func (h heartbeat) marshal() []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, h.PeerID)
+	b = protowire.AppendTag(b, 2, protowire.VarintType)
+	b = protowire.AppendVarint(b, h.Seq)
+	b = protowire.AppendTag(b, 3, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(h.SentNs))
+	b = protowire.AppendTag(b, 4, protowire.BytesType)
+	b = protowire.AppendString(b, h.Version)
+	return b
+}
+
+// unmarshalHeartbeat decodes a protobuf-encoded heartbeat, skipping any
+// unknown fields so the wire format can grow without breaking older readers.
+func unmarshalHeartbeat(data []byte) (heartbeat, error) {
+	var h heartbeat
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return heartbeat{}, fmt.Errorf("invalid heartbeat: bad tag")
+		}
+		data = data[n:]
+
+		switch num {
+		case 1:
+			v, n := protowire.ConsumeString(data)
+			if n < 0 {
+				return heartbeat{}, fmt.Errorf("invalid heartbeat: bad peer_id")
+			}
+			h.PeerID = v
+			data = data[n:]
+		case 2:
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return heartbeat{}, fmt.Errorf("invalid heartbeat: bad seq")
+			}
+			h.Seq = v
+			data = data[n:]
+		case 3:
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return heartbeat{}, fmt.Errorf("invalid heartbeat: bad sent_ns")
+			}
+			h.SentNs = int64(v)
+			data = data[n:]
+		case 4:
+			v, n := protowire.ConsumeString(data)
+			if n < 0 {
+				return heartbeat{}, fmt.Errorf("invalid heartbeat: bad version")
+			}
+			h.Version = v
+			data = data[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return heartbeat{}, fmt.Errorf("invalid heartbeat: bad field %d", num)
+			}
+			data = data[n:]
+		}
+	}
+	return h, nil
+}
+
+// signedHeartbeat bundles a heartbeat with the publisher's signature over
+// its marshaled bytes. Wire format:
+//
+//	message SignedHeartbeat {
+//	  bytes heartbeat = 1;
+//	  bytes signature = 2;
+//	}
+type signedHeartbeat struct {
+	Heartbeat heartbeat
+	Signature []byte
+}
+
+func (shb signedHeartbeat) marshal() []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendBytes(b, shb.Heartbeat.marshal())
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendBytes(b, shb.Signature)
+	return b
+}
+
+func unmarshalSignedHeartbeat(data []byte) (signedHeartbeat, error) {
+	var shb signedHeartbeat
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return signedHeartbeat{}, fmt.Errorf("invalid signed heartbeat: bad tag")
+		}
+		data = data[n:]
+
+		switch num {
+		case 1:
+			v, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return signedHeartbeat{}, fmt.Errorf("invalid signed heartbeat: bad heartbeat field")
+			}
+			hb, err := unmarshalHeartbeat(v)
+			if err != nil {
+				return signedHeartbeat{}, err
+			}
+			shb.Heartbeat = hb
+			data = data[n:]
+		case 2:
+			v, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return signedHeartbeat{}, fmt.Errorf("invalid signed heartbeat: bad signature field")
+			}
+			shb.Signature = append([]byte(nil), v...)
+			data = data[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return signedHeartbeat{}, fmt.Errorf("invalid signed heartbeat: bad field %d", num)
+			}
+			data = data[n:]
+		}
+	}
+	return shb, nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+// loadOrCreateIdentity loads an Ed25519 private key from keyPath, generating
+// and persisting a new one on first run. This gives a node a stable peer ID
+// across restarts so latency measurements can be attributed to the same peer
+// over the lifetime of an experiment.
+func loadOrCreateIdentity(keyPath string) (crypto.PrivKey, error) {
+	if data, err := os.ReadFile(keyPath); err == nil {
+		priv, err := crypto.UnmarshalPrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal node key at %s: %w", keyPath, err)
+		}
+		return priv, nil
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read node key at %s: %w", keyPath, err)
+	}
+
+	priv, _, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate node key: %w", err)
+	}
+
+	data, err := crypto.MarshalPrivateKey(priv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal node key: %w", err)
+	}
+	if err := os.WriteFile(keyPath, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to persist node key to %s: %w", keyPath, err)
+	}
+
+	return priv, nil
+}
@@ -8,6 +8,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -16,19 +17,70 @@ import (
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/libp2p/go-libp2p/core/routing"
+	connmgr "github.com/libp2p/go-libp2p/p2p/net/connmgr"
 	"github.com/multiformats/go-multiaddr"
 )
 
-// The topic name for gossipsub.
-const topicName = "/test/1"
+// defaultNetworkID namespaces the DHT protocol and pubsub topic so that
+// multiple experiments can share the same infrastructure without cross-talk.
+const defaultNetworkID = "default"
+
+// connManagerLowWater and connManagerHighWater are the default watermarks
+// for the libp2p connection manager.
+const (
+	connManagerLowWater  = 100
+	connManagerHighWater = 400
+)
+
+// topicNameForNetwork builds the pubsub topic namespace for a given network
+// ID, e.g. "/p2p-perf/<network-id>/1".
+func topicNameForNetwork(networkID string) string {
+	return fmt.Sprintf("/p2p-perf/%s/1", networkID)
+}
+
+// dhtProtocolPrefix builds the DHT protocol prefix for a given network ID so
+// that peers on different networks never route for each other.
+func dhtProtocolPrefix(networkID string) protocol.ID {
+	return protocol.ID(fmt.Sprintf("/p2p-perf/%s", networkID))
+}
+
+// listenAddrsForTransport builds the libp2p listen multiaddrs for the
+// requested transport(s). "quic" listens on QUIC over UDP (IPv4 and IPv6),
+// "tcp" listens on TCP, and "both" listens on all of the above.
+func listenAddrsForTransport(transport, hostIP string, hostPort int) ([]string, error) {
+	var addrs []string
+
+	switch strings.ToLower(transport) {
+	case "tcp":
+		addrs = append(addrs, fmt.Sprintf("/ip4/%s/tcp/%d", hostIP, hostPort))
+	case "quic":
+		addrs = append(addrs,
+			fmt.Sprintf("/ip4/%s/udp/%d/quic-v1", hostIP, hostPort),
+			fmt.Sprintf("/ip6/::/udp/%d/quic-v1", hostPort),
+		)
+	case "both":
+		addrs = append(addrs,
+			fmt.Sprintf("/ip4/%s/tcp/%d", hostIP, hostPort),
+			fmt.Sprintf("/ip4/%s/udp/%d/quic-v1", hostIP, hostPort),
+			fmt.Sprintf("/ip6/::/udp/%d/quic-v1", hostPort),
+		)
+	default:
+		return nil, fmt.Errorf("unknown transport %q (want tcp, quic, or both)", transport)
+	}
+
+	return addrs, nil
+}
 
 // logMessage defines the structured log format.
 type logMessage struct {
 	Event       string `json:"event"`
 	MsgID       string `json:"msg_id"`
+	Seq         int    `json:"seq,omitempty"`
 	Sender      string `json:"sender,omitempty"`
 	TimestampNs int64  `json:"timestamp_ns"`
+	DelayNs     int64  `json:"delay_ns,omitempty"`
 }
 
 func main() {
@@ -37,8 +89,13 @@ func main() {
 	log.SetOutput(os.Stdout)
 
 	// Define variables to hold flag values.
-	var hostIP, bootstrapPeer string
+	var hostIP, bootstrapPeers, transport, networkID, nodeKeyPath, rendezvous, dataDir string
 	var hostPort, apiPort int
+	var heartbeatInterval time.Duration
+	var connLowWater, connHighWater int
+	var gossipsubD, gossipsubDlo, gossipsubDhi int
+	var gossipsubHeartbeat time.Duration
+	var floodPublish, peerExchange, messageSigning bool
 
 	// Command-line flags for network configuration.
 	//
@@ -54,31 +111,82 @@ func main() {
 	flag.StringVar(&hostIP, "hi", "127.0.0.1", "IP address for the libp2p host (shorthand)")
 
 	// host-port / hp
-	flag.IntVar(&hostPort, "host-port", 9999, "TCP port for the libp2p host (0 for random)")
-	flag.IntVar(&hostPort, "hp", 9999, "TCP port for the libp2p host (shorthand)")
+	flag.IntVar(&hostPort, "host-port", 9999, "Port for the libp2p host (0 for random)")
+	flag.IntVar(&hostPort, "hp", 9999, "Port for the libp2p host (shorthand)")
 
 	// api-port / ap
 	flag.IntVar(&apiPort, "api-port", 8000, "Port for the HTTP API server")
 	flag.IntVar(&apiPort, "ap", 8000, "Port for the HTTP API server (shorthand)")
 
-	// bootstrap-peer / bp
-	flag.StringVar(&bootstrapPeer, "bootstrap-peer", "", "Multiaddress of a bootstrap peer")
-	flag.StringVar(&bootstrapPeer, "bp", "", "Multiaddress of a bootstrap peer (shorthand)")
+	// bootstrap
+	flag.StringVar(&bootstrapPeers, "bootstrap", "", "Comma-separated multiaddrs of bootstrap peers")
+
+	// rendezvous is the string peers advertise and search for via the DHT's
+	// routing discovery. Defaults to the pubsub topic name.
+	flag.StringVar(&rendezvous, "rendezvous", "", "Rendezvous string for peer discovery (default: the pubsub topic name)")
+
+	// transport selects which libp2p transports to listen on.
+	flag.StringVar(&transport, "transport", "tcp", "Transport(s) to listen on: tcp, quic, or both")
+
+	// network-id namespaces the DHT and pubsub topic so unrelated experiments
+	// running on shared infrastructure never talk to each other.
+	flag.StringVar(&networkID, "network-id", defaultNetworkID, "Network ID used to namespace the DHT and pubsub topic")
+
+	// node-key points at a file holding this node's persistent Ed25519
+	// identity, generated on first run if it doesn't exist.
+	flag.StringVar(&nodeKeyPath, "node-key", "node.key", "Path to this node's persistent identity key")
+
+	// conn-low-water / conn-high-water configure the connection manager's
+	// watermarks, so results from NAT-ed nodes stay meaningful even when
+	// swarm sizes differ across experiments.
+	flag.IntVar(&connLowWater, "conn-low-water", connManagerLowWater, "Low watermark for the connection manager")
+	flag.IntVar(&connHighWater, "conn-high-water", connManagerHighWater, "High watermark for the connection manager")
+
+	// heartbeat-interval controls how often this node publishes a signed
+	// liveness heartbeat.
+	flag.DurationVar(&heartbeatInterval, "heartbeat-interval", 5*time.Second, "Interval between signed liveness heartbeats")
+
+	// GossipSub tuning flags, so experiments can reproduce FloodSub-style vs
+	// mesh-tuned behavior instead of being stuck with library defaults.
+	flag.IntVar(&gossipsubD, "gossipsub-D", 0, "GossipSub mesh degree D (0 keeps the library default)")
+	flag.IntVar(&gossipsubDlo, "gossipsub-Dlo", 0, "GossipSub mesh degree Dlo (0 keeps the library default)")
+	flag.IntVar(&gossipsubDhi, "gossipsub-Dhi", 0, "GossipSub mesh degree Dhi (0 keeps the library default)")
+	flag.DurationVar(&gossipsubHeartbeat, "gossipsub-heartbeat", 0, "GossipSub mesh heartbeat interval (0 keeps the library default)")
+	flag.BoolVar(&floodPublish, "flood-publish", true, "Publish to all known topic peers, not just the mesh")
+	flag.BoolVar(&peerExchange, "peer-exchange", false, "Enable GossipSub peer exchange (PX)")
+	flag.BoolVar(&messageSigning, "message-signing", true, "Sign and verify pubsub messages")
+
+	// data-dir is where per-run experiment JSONL/CSV logs are written.
+	flag.StringVar(&dataDir, "data-dir", "./data", "Directory for per-run experiment JSONL/CSV logs")
 
 	flag.Parse()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Construct the listen address for the libp2p host.
-	listenAddr := fmt.Sprintf("/ip4/%s/tcp/%d", hostIP, hostPort)
+	priv, err := loadOrCreateIdentity(nodeKeyPath)
+	if err != nil {
+		log.Fatalf("Failed to load node identity: %v", err)
+	}
+
+	listenAddrs, err := listenAddrsForTransport(transport, hostIP, hostPort)
+	if err != nil {
+		log.Fatalf("Invalid --transport %q: %v", transport, err)
+	}
+
+	cm, err := connmgr.NewConnManager(connLowWater, connHighWater)
+	if err != nil {
+		log.Fatalf("Failed to create connection manager: %v", err)
+	}
 
 	var idht *dht.IpfsDHT
-	var err error
 	h, err := libp2p.New(
-		libp2p.ListenAddrStrings(listenAddr),
+		libp2p.Identity(priv),
+		libp2p.ListenAddrStrings(listenAddrs...),
+		libp2p.ConnectionManager(cm),
+		libp2p.EnableNATService(),
 		libp2p.Routing(func(h host.Host) (routing.PeerRouting, error) {
-			idht, err = dht.New(ctx, h)
+			idht, err = dht.New(ctx, h, dht.ProtocolPrefix(dhtProtocolPrefix(networkID)))
 			return idht, err
 		}),
 	)
@@ -87,11 +195,27 @@ func main() {
 	}
 	defer h.Close()
 
+	h.Network().Notify(newConnectionLogger())
+
+	topicName := topicNameForNetwork(networkID)
+	if rendezvous == "" {
+		rendezvous = topicName
+	}
+
 	// The first line of output is the Peer ID for the orchestrator.
 	fmt.Println(h.ID())
 	fmt.Println(h.Addrs())
 
-	ps, err := pubsub.NewGossipSub(ctx, h)
+	gossipsubOpts := append(gossipSubOptions(gossipSubConfig{
+		D:            gossipsubD,
+		Dlo:          gossipsubDlo,
+		Dhi:          gossipsubDhi,
+		Heartbeat:    gossipsubHeartbeat,
+		FloodPublish: floodPublish,
+		PeerExchange: peerExchange,
+		SignMessages: messageSigning,
+	}), pubsub.WithRawTracer(&meshTracer{}))
+	ps, err := pubsub.NewGossipSub(ctx, h, gossipsubOpts...)
 	if err != nil {
 		log.Fatalf("Failed to create pubsub: %v", err)
 	}
@@ -107,16 +231,37 @@ func main() {
 	}
 
 	// Start a background goroutine to handle incoming messages.
-	go handleMessages(ctx, sub, h.ID())
+	experiments := newExperimentManager(dataDir, 2*heartbeatInterval)
+	go handleMessages(ctx, sub, h.ID(), experiments)
 
-	// If a bootstrap peer is provided, connect to it.
-	if bootstrapPeer != "" {
-		connectToPeer(ctx, h, bootstrapPeer)
+	// Join the dedicated heartbeat topic and start publishing/consuming
+	// signed liveness heartbeats.
+	heartbeatTopic, err := ps.Join(heartbeatTopicName)
+	if err != nil {
+		log.Fatalf("Failed to join heartbeat topic: %v", err)
+	}
+	heartbeatSub, err := heartbeatTopic.Subscribe()
+	if err != nil {
+		log.Fatalf("Failed to subscribe to heartbeat topic: %v", err)
 	}
+	liveness := newLivenessTracker()
+	go startHeartbeat(ctx, heartbeatTopic, h.ID(), priv, heartbeatInterval)
+	go handleHeartbeats(ctx, heartbeatSub, h.ID(), liveness)
+
+	// Connect to every configured bootstrap peer, then bootstrap the DHT and
+	// start advertising/discovering peers under the rendezvous string so the
+	// mesh converges without relying on a single bootstrap link.
+	for _, addr := range splitBootstrapPeers(bootstrapPeers) {
+		connectToPeer(ctx, h, addr)
+	}
+	if err := idht.Bootstrap(ctx); err != nil {
+		log.Printf("DHT bootstrap failed: %v", err)
+	}
+	go runDiscovery(ctx, idht, h, rendezvous)
 
 	// Start an HTTP server to trigger message broadcasts.
 	apiListenAddr := fmt.Sprintf(":%d", apiPort)
-	startAPIServer(apiListenAddr, topic)
+	startAPIServer(ctx, apiListenAddr, topic, liveness, experiments)
 
 	// Wait for a termination signal.
 	sigCh := make(chan os.Signal, 1)
@@ -130,7 +275,7 @@ func main() {
 }
 
 // handleMessages reads messages from the subscription and logs them.
-func handleMessages(ctx context.Context, sub *pubsub.Subscription, selfID peer.ID) {
+func handleMessages(ctx context.Context, sub *pubsub.Subscription, selfID peer.ID, experiments *experimentManager) {
 	for {
 		msg, err := sub.Next(ctx)
 		if err != nil {
@@ -147,13 +292,37 @@ func handleMessages(ctx context.Context, sub *pubsub.Subscription, selfID peer.I
 			continue // Ignore malformed messages.
 		}
 
+		receivedNs := time.Now().UnixNano()
+		sender := msg.GetFrom().String()
 		logJSON(logMessage{
 			Event:       "message_received",
 			MsgID:       bMsg.MsgID,
-			Sender:      msg.GetFrom().String(),
-			TimestampNs: time.Now().UnixNano(),
+			Seq:         bMsg.Seq,
+			Sender:      sender,
+			TimestampNs: receivedNs,
 		})
+
+		messagesReceivedTotal.WithLabelValues(senderPrefix(sender)).Inc()
+		if bMsg.SentNs > 0 {
+			propagationLatencySeconds.Observe(float64(receivedNs-bMsg.SentNs) / float64(time.Second))
+		}
+		if bMsg.RunID != "" {
+			experiments.recordReceive(bMsg.RunID, bMsg.MsgID, sender, bMsg.Seq, bMsg.SentNs, receivedNs)
+		}
+	}
+}
+
+// splitBootstrapPeers parses a comma-separated list of bootstrap multiaddrs,
+// discarding empty entries.
+func splitBootstrapPeers(raw string) []string {
+	var addrs []string
+	for _, addr := range strings.Split(raw, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr != "" {
+			addrs = append(addrs, addr)
+		}
 	}
+	return addrs
 }
 
 // connectToPeer connects the host to a given bootstrap peer.
@@ -0,0 +1,135 @@
+package main
+
+import (
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pubsub_pb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Prometheus metrics, modelled on the guardian's p2p_heartbeats_sent_total /
+// p2p_broadcast_messages_received_total counters. The names here carry their
+// own p2p_perf_ prefix and do not match the guardian's metric names.
+var (
+	messagesPublishedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "p2p_perf_messages_published_total",
+		Help: "Total number of messages published to the broadcast topic.",
+	})
+
+	messagesReceivedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "p2p_perf_messages_received_total",
+		Help: "Total number of messages received on the broadcast topic, labelled by sender peer ID prefix.",
+	}, []string{"sender_prefix"})
+
+	duplicateMessagesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "p2p_perf_duplicate_messages_total",
+		Help: "Total number of duplicate messages observed across all joined pubsub topics.",
+	})
+
+	propagationLatencySeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "p2p_perf_propagation_latency_seconds",
+		Help:    "End-to-end propagation latency from publish to receive.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	meshEventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "p2p_perf_mesh_events_total",
+		Help: "Pubsub mesh graft/prune events, labelled by topic and event type.",
+	}, []string{"topic", "event"})
+
+	controlMessagesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "p2p_perf_control_messages_total",
+		Help: "Pubsub IHAVE/IWANT control messages, labelled by direction and type.",
+	}, []string{"direction", "type"})
+
+	messageValidationTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "p2p_perf_message_validation_total",
+		Help: "Pubsub message validation results, labelled by topic and result.",
+	}, []string{"topic", "result"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		messagesPublishedTotal,
+		messagesReceivedTotal,
+		duplicateMessagesTotal,
+		propagationLatencySeconds,
+		meshEventsTotal,
+		controlMessagesTotal,
+		messageValidationTotal,
+	)
+}
+
+// senderPrefix shortens a peer ID to a stable, low-cardinality label value.
+func senderPrefix(id string) string {
+	const prefixLen = 8
+	if len(id) <= prefixLen {
+		return id
+	}
+	return id[:prefixLen]
+}
+
+// meshTracer is a pubsub.RawTracer that feeds mesh grafts/prunes, IHAVE/IWANT
+// control traffic, and message-validation results into Prometheus so
+// per-topic mesh health can be scraped during long-running perf runs.
+type meshTracer struct{}
+
+var _ pubsub.RawTracer = (*meshTracer)(nil)
+
+func (t *meshTracer) AddPeer(p peer.ID, proto protocol.ID) {}
+func (t *meshTracer) RemovePeer(p peer.ID)                 {}
+func (t *meshTracer) Join(topic string)                    {}
+func (t *meshTracer) Leave(topic string)                   {}
+func (t *meshTracer) DeliverMessage(msg *pubsub.Message) {
+	messageValidationTotal.WithLabelValues(msg.GetTopic(), "accepted").Inc()
+}
+func (t *meshTracer) RejectMessage(msg *pubsub.Message, reason string) {
+	messageValidationTotal.WithLabelValues(msg.GetTopic(), reason).Inc()
+}
+func (t *meshTracer) DuplicateMessage(msg *pubsub.Message) {
+	duplicateMessagesTotal.Inc()
+}
+func (t *meshTracer) ValidateMessage(msg *pubsub.Message) {}
+
+func (t *meshTracer) Graft(p peer.ID, topic string) {
+	meshEventsTotal.WithLabelValues(topic, "graft").Inc()
+}
+
+func (t *meshTracer) Prune(p peer.ID, topic string) {
+	meshEventsTotal.WithLabelValues(topic, "prune").Inc()
+}
+
+func (t *meshTracer) SendRPC(rpc *pubsub.RPC, p peer.ID) {
+	t.countControl(rpc.Control, "sent")
+}
+
+func (t *meshTracer) RecvRPC(rpc *pubsub.RPC) {
+	t.countControl(rpc.Control, "received")
+}
+
+func (t *meshTracer) DropRPC(rpc *pubsub.RPC, p peer.ID) {
+	t.countControl(rpc.Control, "dropped")
+}
+
+func (t *meshTracer) UndeliverableMessage(msg *pubsub.Message) {}
+
+func (t *meshTracer) ThrottlePeer(p peer.ID) {}
+
+func (t *meshTracer) countControl(ctl *pubsub_pb.ControlMessage, direction string) {
+	if ctl == nil {
+		return
+	}
+	if n := len(ctl.GetIhave()); n > 0 {
+		controlMessagesTotal.WithLabelValues(direction, "ihave").Add(float64(n))
+	}
+	if n := len(ctl.GetIwant()); n > 0 {
+		controlMessagesTotal.WithLabelValues(direction, "iwant").Add(float64(n))
+	}
+	if n := len(ctl.GetGraft()); n > 0 {
+		controlMessagesTotal.WithLabelValues(direction, "graft").Add(float64(n))
+	}
+	if n := len(ctl.GetPrune()); n > 0 {
+		controlMessagesTotal.WithLabelValues(direction, "prune").Add(float64(n))
+	}
+}